@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BlockID is the unique identifier for a block.
+type BlockID = uuid.UUID
+
+// BlockMeta is the summary of a block written by either the ingester or the compactor. It is
+// used by the compactor and queriers to find blocks of interest.
+type BlockMeta struct {
+	Version string `json:"format"`
+
+	BlockID BlockID `json:"blockID"`
+
+	MinID []byte `json:"minID"`
+	MaxID []byte `json:"maxID"`
+
+	TenantID string `json:"tenantID"`
+
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+
+	TotalObjects int    `json:"totalObjects"`
+	Size         uint64 `json:"size"`
+
+	// CompactionLevel tracks the number of times a block has been compacted. Level 0 blocks
+	// are written directly by the ingester, level N+1 blocks are produced by compacting
+	// several level N blocks together.
+	CompactionLevel uint8 `json:"compactionLevel"`
+
+	Encoding string `json:"encoding"`
+
+	IndexPageSize   uint32 `json:"indexPageSize"`
+	TotalRecords    uint32 `json:"totalRecords"`
+	DataEncoding    string `json:"dataEncoding"`
+	BloomShardCount uint16 `json:"bloomShards"`
+
+	// Sources lists the BlockIDs this block was compacted from, if any. It is populated by
+	// the compactor and is empty for blocks written directly by the ingester.
+	Sources []BlockID `json:"sources,omitempty"`
+}
+
+// NewBlockMeta creates a new block meta initialized at CompactionLevel 0.
+func NewBlockMeta(tenantID string, blockID BlockID, version string, encoding string, dataEncoding string) *BlockMeta {
+	return &BlockMeta{
+		Version:         version,
+		BlockID:         blockID,
+		TenantID:        tenantID,
+		Encoding:        encoding,
+		DataEncoding:    dataEncoding,
+		CompactionLevel: 0,
+	}
+}
@@ -0,0 +1,20 @@
+package backend
+
+// NoCompactMarkFilename is the name of the marker file written under the tenant's markers
+// key path, in a key named after the block's ID, to quarantine that block from compaction.
+const NoCompactMarkFilename = "nocompact-mark.json"
+
+// Reasons a block can be excluded from compaction.
+const (
+	NoCompactReasonManual          = "manual"
+	NoCompactReasonIndexOutOfOrder = "index-out-of-order"
+	NoCompactReasonTooBig          = "too-big"
+)
+
+// NoCompactMark is written to the backend under the tenant's markers key path to tell the
+// compactor to skip a block until the marker is removed, e.g. because an operator has flagged
+// it for manual inspection.
+type NoCompactMark struct {
+	BlockID BlockID `json:"blockID"`
+	Reason  string  `json:"reason"`
+}
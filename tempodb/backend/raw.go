@@ -0,0 +1,28 @@
+package backend
+
+import "context"
+
+// KeyPath is a list of keys that unambiguously identify a backend object, e.g.
+// {tenantID, blockID.String()}.
+type KeyPath []string
+
+// RawReader is the subset of the backend read interface needed to fetch small objects stored
+// alongside a block, such as its meta.json or a no-compact marker.
+type RawReader interface {
+	Read(ctx context.Context, name string, keypath KeyPath, shouldCache bool) ([]byte, error)
+
+	// List returns the names of keypath's immediate children, i.e. the next key segment of
+	// every object stored under keypath (one level deep, not recursive). Callers that
+	// would otherwise need to probe one candidate key at a time (e.g. checking every block
+	// in a tenant for a marker that few of them actually have) should list the common prefix
+	// those objects live under instead, so the cost is one call regardless of how many
+	// candidates there are.
+	List(ctx context.Context, keypath KeyPath) ([]string, error)
+}
+
+// RawWriter is the subset of the backend write interface needed to write or remove small
+// objects stored alongside a block.
+type RawWriter interface {
+	Write(ctx context.Context, name string, keypath KeyPath, data []byte, shouldCache bool) error
+	Delete(ctx context.Context, name string, keypath KeyPath) error
+}
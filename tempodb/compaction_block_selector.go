@@ -3,6 +3,7 @@ package tempodb
 import (
 	"container/heap"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/grafana/tempo/tempodb/backend"
@@ -11,6 +12,80 @@ import (
 // CompactionBlockSelector is an interface for different algorithms to pick suitable blocks for compaction
 type CompactionBlockSelector interface {
 	BlocksToCompact() ([]*backend.BlockMeta, string)
+
+	// BlocksToCompactBatch returns every disjoint group of blocks the selector can currently
+	// find, along with a hash string per group, so the caller can dispatch them in parallel.
+	// Groups returned together never share a block.
+	BlocksToCompactBatch() ([][]*backend.BlockMeta, []string)
+}
+
+// filterNoCompactBlocks returns blocklist with every block carrying a no-compact marker
+// removed. Selectors scan their blocklist under the assumption that adjacent entries are
+// adjacent in time, so a marked block is dropped entirely here rather than skipped in place -
+// from the selector's point of view it was never there, and the windows on either side of it
+// stay contiguous.
+func filterNoCompactBlocks(blocklist []*backend.BlockMeta, noCompactBlocks map[backend.BlockID]*backend.NoCompactMark) []*backend.BlockMeta {
+	if len(noCompactBlocks) == 0 {
+		return blocklist
+	}
+
+	filtered := make([]*backend.BlockMeta, 0, len(blocklist))
+	for _, b := range blocklist {
+		if _, marked := noCompactBlocks[b.BlockID]; marked {
+			continue
+		}
+		filtered = append(filtered, b)
+	}
+
+	return filtered
+}
+
+// excludeFreshestBlock returns blocklist with the single freshest block in the smallest range
+// currently in play removed, so operators have a window to piece-wise copy it out of the
+// backend before compaction rewrites it. If levelAware is true, "smallest range" means the
+// lowest backend.BlockMeta.CompactionLevel present (e.g. level 0), and "freshest" is the
+// largest EndTime among blocks at that level - this avoids excluding a larger, older-by-level
+// block just because it happens to sort last. If levelAware is false there's no level
+// information to consult, so it falls back to excluding the single block with the newest
+// EndTime across the whole list.
+func excludeFreshestBlock(blocklist []*backend.BlockMeta, levelAware bool) []*backend.BlockMeta {
+	if len(blocklist) == 0 {
+		return blocklist
+	}
+
+	candidates := blocklist
+	if levelAware {
+		minLevel := blocklist[0].CompactionLevel
+		for _, b := range blocklist[1:] {
+			if b.CompactionLevel < minLevel {
+				minLevel = b.CompactionLevel
+			}
+		}
+
+		candidates = make([]*backend.BlockMeta, 0, len(blocklist))
+		for _, b := range blocklist {
+			if b.CompactionLevel == minLevel {
+				candidates = append(candidates, b)
+			}
+		}
+	}
+
+	freshest := candidates[0]
+	for _, b := range candidates[1:] {
+		if b.EndTime.After(freshest.EndTime) {
+			freshest = b
+		}
+	}
+
+	filtered := make([]*backend.BlockMeta, 0, len(blocklist)-1)
+	for _, b := range blocklist {
+		if b == freshest {
+			continue
+		}
+		filtered = append(filtered, b)
+	}
+
+	return filtered
 }
 
 /*************************** Simple Block Selector **************************/
@@ -23,7 +98,48 @@ type simpleBlockSelector struct {
 
 var _ (CompactionBlockSelector) = (*simpleBlockSelector)(nil)
 
+// newSimpleBlockSelector builds a simpleBlockSelector. cfg.RetainFreshBlockWindow and whether
+// cfg.Leveled is set together control fresh-block exclusion; cfg may be nil, which behaves
+// like DefaultCompactorConfig().
+func newSimpleBlockSelector(blocklist []*backend.BlockMeta, maxCompactionRange time.Duration, noCompactBlocks map[backend.BlockID]*backend.NoCompactMark, cfg *CompactorConfig) CompactionBlockSelector {
+	blocklist = filterNoCompactBlocks(blocklist, noCompactBlocks)
+	if cfg.retainFreshBlockWindow() {
+		blocklist = excludeFreshestBlock(blocklist, cfg.levelAware())
+	}
+
+	return &simpleBlockSelector{
+		blocklist:          blocklist,
+		MaxCompactionRange: maxCompactionRange,
+	}
+}
+
+// BlocksToCompact returns the next single group, for callers that haven't moved to the
+// parallel BlocksToCompactBatch path yet. It's a thin wrapper around nextGroup, kept only for
+// compatibility.
 func (sbs *simpleBlockSelector) BlocksToCompact() ([]*backend.BlockMeta, string) {
+	return sbs.nextGroup()
+}
+
+// BlocksToCompactBatch drains every disjoint group the selector can currently find in one
+// call, so the caller can dispatch them all in parallel instead of serializing one group at a
+// time.
+func (sbs *simpleBlockSelector) BlocksToCompactBatch() ([][]*backend.BlockMeta, []string) {
+	var groups [][]*backend.BlockMeta
+	var hashes []string
+
+	for {
+		blocks, hash := sbs.nextGroup()
+		if blocks == nil {
+			break
+		}
+		groups = append(groups, blocks)
+		hashes = append(hashes, hash)
+	}
+
+	return groups, hashes
+}
+
+func (sbs *simpleBlockSelector) nextGroup() ([]*backend.BlockMeta, string) {
 	// should never happen
 	if inputBlocks > len(sbs.blocklist) {
 		return nil, ""
@@ -59,7 +175,15 @@ type timeWindowBlockSelector struct {
 
 var _ (CompactionBlockSelector) = (*timeWindowBlockSelector)(nil)
 
-func newTimeWindowBlockSelector(blocklist []*backend.BlockMeta, maxCompactionRange time.Duration) CompactionBlockSelector {
+// newTimeWindowBlockSelector builds a timeWindowBlockSelector. cfg.RetainFreshBlockWindow and
+// whether cfg.Leveled is set together control fresh-block exclusion; cfg may be nil, which
+// behaves like DefaultCompactorConfig().
+func newTimeWindowBlockSelector(blocklist []*backend.BlockMeta, maxCompactionRange time.Duration, noCompactBlocks map[backend.BlockID]*backend.NoCompactMark, cfg *CompactorConfig) CompactionBlockSelector {
+	blocklist = filterNoCompactBlocks(blocklist, noCompactBlocks)
+	if cfg.retainFreshBlockWindow() {
+		blocklist = excludeFreshestBlock(blocklist, cfg.levelAware())
+	}
+
 	twbs := &timeWindowBlockSelector{
 		blocklist:          blocklist,
 		MaxCompactionRange: maxCompactionRange,
@@ -68,7 +192,33 @@ func newTimeWindowBlockSelector(blocklist []*backend.BlockMeta, maxCompactionRan
 	return twbs
 }
 
+// BlocksToCompact returns the next single group, for callers that haven't moved to the
+// parallel BlocksToCompactBatch path yet. It's a thin wrapper around nextGroup, kept only for
+// compatibility.
 func (twbs *timeWindowBlockSelector) BlocksToCompact() ([]*backend.BlockMeta, string) {
+	return twbs.nextGroup()
+}
+
+// BlocksToCompactBatch drains every disjoint time-window group the selector can currently
+// find in one call, e.g. several non-overlapping level-0 groupings inside the same tenant, so
+// they can be dispatched for compaction in parallel.
+func (twbs *timeWindowBlockSelector) BlocksToCompactBatch() ([][]*backend.BlockMeta, []string) {
+	var groups [][]*backend.BlockMeta
+	var hashes []string
+
+	for {
+		blocks, hash := twbs.nextGroup()
+		if blocks == nil {
+			break
+		}
+		groups = append(groups, blocks)
+		hashes = append(hashes, hash)
+	}
+
+	return groups, hashes
+}
+
+func (twbs *timeWindowBlockSelector) nextGroup() ([]*backend.BlockMeta, string) {
 	var blocksToCompact BlockMetaHeap
 
 	for twbs.cursor < len(twbs.blocklist) {
@@ -91,13 +241,17 @@ func (twbs *timeWindowBlockSelector) BlocksToCompact() ([]*backend.BlockMeta, st
 			cursorEnd++
 		}
 
-		// if we found enough blocks, huzzah!  return them and we'll check this time window again next loop
+		// if we found enough blocks, huzzah!  return them and advance the cursor past this
+		// window so a repeated call (e.g. from BlocksToCompactBatch's drain loop) moves on
+		// instead of finding the same window again
 		if len(blocksToCompact) >= inputBlocks {
 			// pop all
 			for len(blocksToCompact) > inputBlocks {
 				heap.Pop(&blocksToCompact)
 			}
 
+			twbs.cursor = cursorEnd
+
 			return blocksToCompact, fmt.Sprintf("%v-%v", cursorBlock.TenantID, currentWindow)
 		}
 
@@ -111,6 +265,158 @@ func (twbs *timeWindowBlockSelector) windowForBlock(meta *backend.BlockMeta) int
 	return meta.StartTime.Unix() / int64(twbs.MaxCompactionRange/time.Second)
 }
 
+/*************************** Leveled Block Selector **************************/
+
+// LeveledCompactionConfig configures the leveled block selector. Blocks are grouped into
+// exponentially growing time windows, similar to Prometheus TSDB's ExponentialBlockRanges:
+// level 0 windows are MinBlockRange wide, level 1 windows are MinBlockRange*LevelMultiplier
+// wide, and so on up to MaxLevels.
+type LeveledCompactionConfig struct {
+	MinBlockRange   time.Duration
+	LevelMultiplier int
+	MaxLevels       int
+}
+
+// levelWindowKey identifies a single per-level compaction window.
+type levelWindowKey struct {
+	level  uint8
+	window int64
+}
+
+// leveledBlockSelector groups blocks into per-level time windows and only emits a group once
+// a window at level L is completely filled with level-L blocks. Compacting a full window
+// promotes its output block to level L+1.
+//
+// Blocks are bucketed by (level, window) rather than scanned as one contiguous run: a
+// promoted block's StartTime is the minimum of its sources, so once promotion has happened at
+// least once, blocks belonging to the same window are no longer guaranteed to be adjacent in a
+// StartTime-sorted blocklist - a later level-0 window's blocks can sort in between an earlier
+// window's level-1 promotion and its own. Bucketing by key is immune to how the blocks happen
+// to interleave in sorted order.
+type leveledBlockSelector struct {
+	blocklist []*backend.BlockMeta
+	cfg       LeveledCompactionConfig
+
+	buckets map[levelWindowKey][]*backend.BlockMeta
+	keys    []levelWindowKey
+	cursor  int
+}
+
+var _ (CompactionBlockSelector) = (*leveledBlockSelector)(nil)
+
+// newLeveledBlockSelector builds a leveledBlockSelector. levelCfg configures the per-level
+// windows; cfg.RetainFreshBlockWindow and whether cfg.Leveled is set together control
+// fresh-block exclusion the same way they do for the other selectors - cfg may be nil, which
+// behaves like DefaultCompactorConfig().
+func newLeveledBlockSelector(blocklist []*backend.BlockMeta, levelCfg LeveledCompactionConfig, noCompactBlocks map[backend.BlockID]*backend.NoCompactMark, cfg *CompactorConfig) CompactionBlockSelector {
+	blocklist = filterNoCompactBlocks(blocklist, noCompactBlocks)
+	if cfg.retainFreshBlockWindow() {
+		blocklist = excludeFreshestBlock(blocklist, cfg.levelAware())
+	}
+
+	lbs := &leveledBlockSelector{
+		blocklist: blocklist,
+		cfg:       levelCfg,
+		buckets:   make(map[levelWindowKey][]*backend.BlockMeta),
+	}
+
+	for _, b := range blocklist {
+		key := levelWindowKey{level: b.CompactionLevel, window: lbs.windowForBlock(b, b.CompactionLevel)}
+		if _, ok := lbs.buckets[key]; !ok {
+			lbs.keys = append(lbs.keys, key)
+		}
+		lbs.buckets[key] = append(lbs.buckets[key], b)
+	}
+
+	sort.Slice(lbs.keys, func(i, j int) bool {
+		if lbs.keys[i].level != lbs.keys[j].level {
+			return lbs.keys[i].level < lbs.keys[j].level
+		}
+		return lbs.keys[i].window < lbs.keys[j].window
+	})
+
+	return lbs
+}
+
+// BlocksToCompact returns the next single group, for callers that haven't moved to the
+// parallel BlocksToCompactBatch path yet. It's a thin wrapper around nextGroup, kept only for
+// compatibility.
+func (lbs *leveledBlockSelector) BlocksToCompact() ([]*backend.BlockMeta, string) {
+	return lbs.nextGroup()
+}
+
+// BlocksToCompactBatch drains every disjoint level window the selector can currently find in
+// one call, across all levels, so independent windows can be dispatched for compaction
+// concurrently instead of one at a time.
+func (lbs *leveledBlockSelector) BlocksToCompactBatch() ([][]*backend.BlockMeta, []string) {
+	var groups [][]*backend.BlockMeta
+	var hashes []string
+
+	for {
+		blocks, hash := lbs.nextGroup()
+		if blocks == nil {
+			break
+		}
+		groups = append(groups, blocks)
+		hashes = append(hashes, hash)
+	}
+
+	return groups, hashes
+}
+
+func (lbs *leveledBlockSelector) nextGroup() ([]*backend.BlockMeta, string) {
+	for lbs.cursor < len(lbs.keys) {
+		key := lbs.keys[lbs.cursor]
+		bucket := lbs.buckets[key]
+
+		// only promote a window to level+1 once it's entirely full and there's somewhere left
+		// to promote it to. otherwise leave this bucket alone (and move to the next key), it
+		// may fill up on a later poll.
+		if len(bucket) >= inputBlocks && int(key.level)+1 < lbs.cfg.MaxLevels {
+			group := bucket[:inputBlocks]
+			// leave any remainder in the bucket so a repeated call drains it instead of
+			// re-emitting the blocks just returned
+			lbs.buckets[key] = bucket[inputBlocks:]
+			hashString := fmt.Sprintf("%v-%v-%v", group[0].TenantID, key.level, key.window)
+
+			return group, hashString
+		}
+
+		lbs.cursor++
+	}
+
+	return nil, ""
+}
+
+// rangeForLevel returns the width of a compaction window at the given level.
+func (lbs *leveledBlockSelector) rangeForLevel(level uint8) time.Duration {
+	r := lbs.cfg.MinBlockRange
+	for i := uint8(0); i < level; i++ {
+		r *= time.Duration(lbs.cfg.LevelMultiplier)
+	}
+	return r
+}
+
+// windowForBlock returns the aligned start (in unix nanoseconds) of the level-appropriate
+// window containing meta, i.e. t0 = range * floor(startTime/range), matching the rounding
+// of Prometheus TSDB's splitByRange so negative/pre-epoch times round toward -inf rather
+// than toward zero.
+func (lbs *leveledBlockSelector) windowForBlock(meta *backend.BlockMeta, level uint8) int64 {
+	r := lbs.rangeForLevel(level).Nanoseconds()
+	t := meta.StartTime.UnixNano()
+
+	return floorDiv(t, r) * r
+}
+
+// floorDiv is integer division that rounds toward negative infinity instead of toward zero.
+func floorDiv(a, b int64) int64 {
+	d := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		d--
+	}
+	return d
+}
+
 type BlockMetaHeap []*backend.BlockMeta
 
 func (h BlockMetaHeap) Len() int {
@@ -0,0 +1,315 @@
+package tempodb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+func newTestBlockMeta(tenant string, start, end time.Time, level uint8, totalObjects int) *backend.BlockMeta {
+	return &backend.BlockMeta{
+		BlockID:         uuid.New(),
+		TenantID:        tenant,
+		StartTime:       start,
+		EndTime:         end,
+		CompactionLevel: level,
+		TotalObjects:    totalObjects,
+	}
+}
+
+func TestLeveledBlockSelectorPromotesAFullWindow(t *testing.T) {
+	cfg := LeveledCompactionConfig{
+		MinBlockRange:   time.Hour,
+		LevelMultiplier: 3,
+		MaxLevels:       3,
+	}
+
+	windowStart := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var blocklist []*backend.BlockMeta
+	for i := 0; i < inputBlocks; i++ {
+		start := windowStart.Add(time.Duration(i) * time.Minute)
+		blocklist = append(blocklist, newTestBlockMeta("test-tenant", start, start.Add(time.Minute), 0, 100))
+	}
+
+	lbs := newLeveledBlockSelector(blocklist, cfg, nil, &CompactorConfig{RetainFreshBlockWindow: false})
+
+	got, hash := lbs.BlocksToCompact()
+	if got == nil {
+		t.Fatal("expected a full level-0 window to be selected for compaction")
+	}
+	if len(got) != inputBlocks {
+		t.Fatalf("expected %d blocks, got %d", inputBlocks, len(got))
+	}
+	if !strings.Contains(hash, "-0-") {
+		t.Fatalf("expected hash to tag level 0, got %q", hash)
+	}
+
+	// nothing left to compact
+	got, _ = lbs.BlocksToCompact()
+	if got != nil {
+		t.Fatalf("expected no further groups, got %v", got)
+	}
+}
+
+func TestLeveledBlockSelectorLeavesAHalfFullWindowAlone(t *testing.T) {
+	cfg := LeveledCompactionConfig{
+		MinBlockRange:   time.Hour,
+		LevelMultiplier: 3,
+		MaxLevels:       3,
+	}
+
+	windowStart := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var blocklist []*backend.BlockMeta
+	for i := 0; i < inputBlocks-1; i++ {
+		start := windowStart.Add(time.Duration(i) * time.Minute)
+		blocklist = append(blocklist, newTestBlockMeta("test-tenant", start, start.Add(time.Minute), 0, 100))
+	}
+
+	lbs := newLeveledBlockSelector(blocklist, cfg, nil, &CompactorConfig{RetainFreshBlockWindow: false})
+
+	got, _ := lbs.BlocksToCompact()
+	if got != nil {
+		t.Fatalf("expected a half-full window to be left alone, got %v", got)
+	}
+}
+
+func TestLeveledBlockSelectorFindsAFullWindowInterleavedWithAnotherLevel(t *testing.T) {
+	cfg := LeveledCompactionConfig{
+		MinBlockRange:   time.Hour,
+		LevelMultiplier: 3,
+		MaxLevels:       3,
+	}
+
+	windowStart := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// a level-1 block already promoted out of an earlier window - its StartTime (the min of
+	// its sources) falls before this window's level-0 blocks, same as it would in practice
+	promoted := newTestBlockMeta("test-tenant", windowStart.Add(-30*time.Minute), windowStart, 1, 300)
+
+	var blocklist []*backend.BlockMeta
+	blocklist = append(blocklist, promoted)
+	for i := 0; i < inputBlocks; i++ {
+		start := windowStart.Add(time.Duration(i) * time.Minute)
+		blocklist = append(blocklist, newTestBlockMeta("test-tenant", start, start.Add(time.Minute), 0, 100))
+	}
+	// one more level-0 block from a later, not-yet-full window, sorted after this window's
+	// level-0 blocks
+	blocklist = append(blocklist, newTestBlockMeta("test-tenant", windowStart.Add(90*time.Minute), windowStart.Add(91*time.Minute), 0, 100))
+
+	lbs := newLeveledBlockSelector(blocklist, cfg, nil, &CompactorConfig{RetainFreshBlockWindow: false})
+
+	got, hash := lbs.BlocksToCompact()
+	if got == nil {
+		t.Fatal("expected the full level-0 window to be found despite interleaving with another level")
+	}
+	if len(got) != inputBlocks {
+		t.Fatalf("expected %d blocks, got %d", inputBlocks, len(got))
+	}
+	if !strings.Contains(hash, "-0-") {
+		t.Fatalf("expected hash to tag level 0, got %q", hash)
+	}
+	for _, b := range got {
+		if b.BlockID == promoted.BlockID {
+			t.Fatal("the already-promoted level-1 block must not be swept into a level-0 group")
+		}
+	}
+}
+
+func TestLeveledBlockSelectorSkipsMarkedBlocksWithoutBreakingTheWindow(t *testing.T) {
+	cfg := LeveledCompactionConfig{
+		MinBlockRange:   time.Hour,
+		LevelMultiplier: 3,
+		MaxLevels:       3,
+	}
+
+	windowStart := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// one more block than inputBlocks requires, so the window still fills once the marked
+	// block is excluded
+	var blocklist []*backend.BlockMeta
+	for i := 0; i < inputBlocks+1; i++ {
+		start := windowStart.Add(time.Duration(i) * time.Minute)
+		blocklist = append(blocklist, newTestBlockMeta("test-tenant", start, start.Add(time.Minute), 0, 100))
+	}
+
+	marked := blocklist[len(blocklist)/2]
+	noCompact := map[backend.BlockID]*backend.NoCompactMark{
+		marked.BlockID: {BlockID: marked.BlockID, Reason: backend.NoCompactReasonManual},
+	}
+
+	lbs := newLeveledBlockSelector(blocklist, cfg, noCompact, &CompactorConfig{RetainFreshBlockWindow: false})
+
+	got, _ := lbs.BlocksToCompact()
+	if got == nil {
+		t.Fatal("expected the surrounding blocks to still form a window")
+	}
+	for _, b := range got {
+		if b.BlockID == marked.BlockID {
+			t.Fatal("marked block should have been excluded from the window")
+		}
+	}
+}
+
+func TestLeveledBlockSelectorExcludesFreshestBlockWhenRetainFreshBlockWindowIsSet(t *testing.T) {
+	cfg := LeveledCompactionConfig{
+		MinBlockRange:   time.Hour,
+		LevelMultiplier: 3,
+		MaxLevels:       3,
+	}
+
+	windowStart := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// one more block than inputBlocks requires, so the window still fills once the freshest
+	// block is excluded
+	var blocklist []*backend.BlockMeta
+	for i := 0; i < inputBlocks+1; i++ {
+		start := windowStart.Add(time.Duration(i) * time.Minute)
+		blocklist = append(blocklist, newTestBlockMeta("test-tenant", start, start.Add(time.Minute), 0, 100))
+	}
+
+	freshest := blocklist[len(blocklist)-1]
+	for _, b := range blocklist[:len(blocklist)-1] {
+		b.EndTime = freshest.EndTime.Add(-time.Hour)
+	}
+
+	lbs := newLeveledBlockSelector(blocklist, cfg, nil, &CompactorConfig{RetainFreshBlockWindow: true, Leveled: &cfg})
+
+	got, _ := lbs.BlocksToCompact()
+	if got == nil {
+		t.Fatal("expected the surrounding blocks to still form a window")
+	}
+	for _, b := range got {
+		if b.BlockID == freshest.BlockID {
+			t.Fatal("freshest block should have been excluded from the window")
+		}
+	}
+}
+
+func TestLeveledBlockSelectorWindowAlignment(t *testing.T) {
+	lbs := &leveledBlockSelector{
+		cfg: LeveledCompactionConfig{
+			MinBlockRange:   time.Hour,
+			LevelMultiplier: 3,
+			MaxLevels:       3,
+		},
+	}
+
+	hour := time.Hour.Nanoseconds()
+
+	tests := []struct {
+		name      string
+		startTime time.Time
+		want      int64
+	}{
+		{"epoch", time.Unix(0, 0).UTC(), 0},
+		{"within first hour", time.Unix(0, 0).UTC().Add(45 * time.Minute), 0},
+		{"exactly on a later boundary", time.Unix(0, 0).UTC().Add(2 * time.Hour), 2 * hour},
+		{"just before epoch", time.Unix(0, 0).UTC().Add(-time.Minute), -hour},
+		{"ninety minutes before epoch", time.Unix(0, 0).UTC().Add(-90 * time.Minute), -2 * hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := &backend.BlockMeta{StartTime: tt.startTime}
+			got := lbs.windowForBlock(meta, 0)
+			if got != tt.want {
+				t.Fatalf("windowForBlock(%v) = %d, want %d", tt.startTime, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloorDiv(t *testing.T) {
+	tests := []struct {
+		a, b, want int64
+	}{
+		{4, 2, 2},
+		{-4, 2, -2},
+		{-3, 2, -2},
+		{3, 2, 1},
+		{-1, 2, -1},
+		{0, 2, 0},
+	}
+
+	for _, tt := range tests {
+		got := floorDiv(tt.a, tt.b)
+		if got != tt.want {
+			t.Fatalf("floorDiv(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestBlocksToCompactBatchGroupsAreDisjoint(t *testing.T) {
+	maxCompactionRange := time.Hour
+
+	var blocklist []*backend.BlockMeta
+	// two separate windows, each with exactly enough blocks to form one group
+	for _, windowStart := range []time.Time{
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 1, 6, 0, 0, 0, time.UTC),
+	} {
+		for i := 0; i < inputBlocks; i++ {
+			start := windowStart.Add(time.Duration(i) * time.Minute)
+			blocklist = append(blocklist, newTestBlockMeta("test-tenant", start, start.Add(time.Minute), 0, 100))
+		}
+	}
+
+	// disable fresh-block retention here, it's covered by its own tests and would otherwise
+	// strip a block out of whichever window holds the overall freshest block
+	twbs := newTimeWindowBlockSelector(blocklist, maxCompactionRange, nil, &CompactorConfig{RetainFreshBlockWindow: false})
+
+	groups, hashes := twbs.BlocksToCompactBatch()
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 disjoint groups, got %d", len(groups))
+	}
+	if len(hashes) != len(groups) {
+		t.Fatalf("expected one hash per group, got %d hashes for %d groups", len(hashes), len(groups))
+	}
+
+	seen := make(map[backend.BlockID]bool)
+	for _, group := range groups {
+		for _, b := range group {
+			if seen[b.BlockID] {
+				t.Fatalf("block %v appeared in more than one group", b.BlockID)
+			}
+			seen[b.BlockID] = true
+		}
+	}
+}
+
+func TestExcludeFreshestBlockIsLevelAwareNotNewestByID(t *testing.T) {
+	now := time.Now()
+
+	// the larger, older level-1 block happens to be appended last (i.e. "newest by ID" in
+	// a naive sense) and also has the latest EndTime of the whole list, but it's not in the
+	// shortest range in play - level 0 is - so it must stay eligible for compaction
+	level0Older := newTestBlockMeta("test-tenant", now.Add(-2*time.Hour), now.Add(-2*time.Hour), 0, 10)
+	level0Newer := newTestBlockMeta("test-tenant", now.Add(-time.Hour), now.Add(-time.Hour), 0, 10)
+	level1Larger := newTestBlockMeta("test-tenant", now.Add(-3*time.Hour), now, 1, 1000)
+
+	blocklist := []*backend.BlockMeta{level0Older, level0Newer, level1Larger}
+
+	got := excludeFreshestBlock(blocklist, true /* levelAware */)
+
+	foundLevel1 := false
+	for _, b := range got {
+		if b.BlockID == level0Newer.BlockID {
+			t.Fatal("expected the freshest level-0 block to be excluded")
+		}
+		if b.BlockID == level1Larger.BlockID {
+			foundLevel1 = true
+		}
+	}
+	if !foundLevel1 {
+		t.Fatal("expected the larger level-1 block to remain eligible for compaction")
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected exactly one block excluded, got %d remaining", len(got))
+	}
+}
@@ -0,0 +1,134 @@
+package tempodb
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+var metricMarkedForDeletion = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempodb",
+	Subsystem: "compactor",
+	Name:      "blocks_marked_for_deletion_total",
+	Help:      "Total number of blocks marked for deletion.",
+}, []string{"reason"})
+
+// DedupeFilter removes blocks from a blocklist that are already fully covered by a newer
+// compacted block, as tracked by backend.BlockMeta.Sources. It runs on every poll cycle,
+// before the blocklist reaches either CompactionBlockSelector, so selectors never see a block
+// that has already been superseded.
+type DedupeFilter struct{}
+
+// NewDedupeFilter creates a DedupeFilter.
+func NewDedupeFilter() *DedupeFilter {
+	return &DedupeFilter{}
+}
+
+// Apply returns blocklist with superseded blocks removed.
+//
+// A block is dropped outright if its ID appears in some other present block's Sources - that
+// covers chains of compactions too, since a mid-chain block's own Sources are still counted
+// even though it is itself about to be dropped (e.g. A,B,C compact into X, and X,Y,Z later
+// compact into W: W.Sources references X, so X is dropped, and X.Sources references A/B/C, so
+// those are dropped too, without ever needing to walk the chain).
+//
+// What's left after that - blocks nobody references as a source - are never merged together
+// just because they happen to share a source with one another: two blocks that each compacted
+// a different, overlapping-but-not-identical set of sources (e.g. a genuine race between two
+// independent compactions) are both kept. Only when two such blocks have equal Sources sets,
+// or one's Sources are a subset of the other's, are they treated as duplicates of each other.
+// With equal Sources the one with fewer TotalObjects is dropped (on a tie, the one later in
+// blocklist); with a strict subset the block whose Sources are the subset is dropped,
+// whatever its TotalObjects. Blocks with no Sources are never
+// compared this way and so are never dropped by this step (they can still be dropped by the
+// first step, if referenced by a newer block).
+func (f *DedupeFilter) Apply(blocklist []*backend.BlockMeta) []*backend.BlockMeta {
+	byID := make(map[backend.BlockID]*backend.BlockMeta, len(blocklist))
+	for _, b := range blocklist {
+		byID[b.BlockID] = b
+	}
+
+	isSource := make(map[backend.BlockID]bool)
+	for _, b := range blocklist {
+		for _, src := range b.Sources {
+			if _, ok := byID[src]; ok {
+				isSource[src] = true
+			}
+		}
+	}
+
+	var finalists []*backend.BlockMeta
+	dropped := 0
+	for _, b := range blocklist {
+		if isSource[b.BlockID] {
+			dropped++
+			continue
+		}
+		finalists = append(finalists, b)
+	}
+
+	drop := make(map[*backend.BlockMeta]bool)
+	for i := 0; i < len(finalists); i++ {
+		a := finalists[i]
+		if len(a.Sources) == 0 {
+			continue
+		}
+		for j := i + 1; j < len(finalists); j++ {
+			b := finalists[j]
+			if len(b.Sources) == 0 {
+				continue
+			}
+
+			aSubB := isSourceSubset(a.Sources, b.Sources)
+			bSubA := isSourceSubset(b.Sources, a.Sources)
+
+			switch {
+			case aSubB && bSubA:
+				// identical source sets - two compactions raced over the same blocks, keep
+				// whichever one actually wrote more data
+				if a.TotalObjects >= b.TotalObjects {
+					drop[b] = true
+				} else {
+					drop[a] = true
+				}
+			case aSubB:
+				// a's sources are fully covered by b's, so a is redundant
+				drop[a] = true
+			case bSubA:
+				drop[b] = true
+			}
+		}
+	}
+
+	kept := make([]*backend.BlockMeta, 0, len(finalists))
+	for _, b := range finalists {
+		if drop[b] {
+			dropped++
+			continue
+		}
+		kept = append(kept, b)
+	}
+
+	if dropped > 0 {
+		metricMarkedForDeletion.WithLabelValues("duplicate").Add(float64(dropped))
+	}
+
+	return kept
+}
+
+// isSourceSubset reports whether every BlockID in a is also present in b.
+func isSourceSubset(a, b []backend.BlockID) bool {
+	bSet := make(map[backend.BlockID]bool, len(b))
+	for _, id := range b {
+		bSet[id] = true
+	}
+
+	for _, id := range a {
+		if !bSet[id] {
+			return false
+		}
+	}
+
+	return true
+}
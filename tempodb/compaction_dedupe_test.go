@@ -0,0 +1,91 @@
+package tempodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+func TestDedupeFilterDropsSourcesOfANewerBlock(t *testing.T) {
+	now := time.Now()
+	a := newTestBlockMeta("test-tenant", now, now, 0, 100)
+	b := newTestBlockMeta("test-tenant", now, now, 0, 100)
+	c := newTestBlockMeta("test-tenant", now, now, 0, 100)
+
+	compacted := newTestBlockMeta("test-tenant", now, now, 1, 300)
+	compacted.Sources = []backend.BlockID{a.BlockID, b.BlockID, c.BlockID}
+
+	f := NewDedupeFilter()
+	kept := f.Apply([]*backend.BlockMeta{a, b, c, compacted})
+
+	if len(kept) != 1 || kept[0].BlockID != compacted.BlockID {
+		t.Fatalf("expected only the compacted block to survive, got %v", kept)
+	}
+}
+
+func TestDedupeFilterKeepsTheLargerOfTwoIdenticalSourceSets(t *testing.T) {
+	now := time.Now()
+	x := newTestBlockMeta("test-tenant", now, now, 0, 100)
+	y := newTestBlockMeta("test-tenant", now, now, 0, 100)
+	z := newTestBlockMeta("test-tenant", now, now, 0, 100)
+
+	sources := []backend.BlockID{x.BlockID, y.BlockID, z.BlockID}
+
+	smaller := newTestBlockMeta("test-tenant", now, now, 1, 250)
+	smaller.Sources = sources
+
+	larger := newTestBlockMeta("test-tenant", now, now, 1, 300)
+	larger.Sources = sources
+
+	f := NewDedupeFilter()
+	kept := f.Apply([]*backend.BlockMeta{x, y, z, smaller, larger})
+
+	if len(kept) != 1 || kept[0].BlockID != larger.BlockID {
+		t.Fatalf("expected only the larger duplicate to survive, got %v", kept)
+	}
+}
+
+func TestDedupeFilterNeverDropsBlocksWithEmptySources(t *testing.T) {
+	now := time.Now()
+	a := newTestBlockMeta("test-tenant", now, now, 0, 100)
+	b := newTestBlockMeta("test-tenant", now, now, 0, 100)
+
+	f := NewDedupeFilter()
+	kept := f.Apply([]*backend.BlockMeta{a, b})
+
+	if len(kept) != 2 {
+		t.Fatalf("expected both pre-migration blocks with no Sources to survive, got %v", kept)
+	}
+}
+
+func TestDedupeFilterKeepsOverlappingButDistinctCompactions(t *testing.T) {
+	now := time.Now()
+	x := newTestBlockMeta("test-tenant", now, now, 0, 100)
+	y := newTestBlockMeta("test-tenant", now, now, 0, 100)
+	z := newTestBlockMeta("test-tenant", now, now, 0, 100)
+
+	// a and b each compacted a different, merely overlapping set of sources - a genuine race
+	// between two independent compactions, not a duplicate
+	a := newTestBlockMeta("test-tenant", now, now, 1, 100)
+	a.Sources = []backend.BlockID{x.BlockID, y.BlockID}
+
+	b := newTestBlockMeta("test-tenant", now, now, 1, 100)
+	b.Sources = []backend.BlockID{y.BlockID, z.BlockID}
+
+	f := NewDedupeFilter()
+	kept := f.Apply([]*backend.BlockMeta{x, y, z, a, b})
+
+	foundA, foundB := false, false
+	for _, k := range kept {
+		if k.BlockID == a.BlockID {
+			foundA = true
+		}
+		if k.BlockID == b.BlockID {
+			foundB = true
+		}
+	}
+	if !foundA || !foundB {
+		t.Fatalf("expected both a and b to survive since they aren't true duplicates, got %v", kept)
+	}
+}
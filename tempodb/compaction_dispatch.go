@@ -0,0 +1,41 @@
+package tempodb
+
+import (
+	"sync"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// maxParallelCompactions bounds how many of the groups returned by a single
+// BlocksToCompactBatch call the compaction loop will compact at the same time.
+const maxParallelCompactions = 4
+
+// compactBatch dispatches every group returned by selector.BlocksToCompactBatch to its own
+// goroutine, bounded by a semaphore sized at maxParallelCompactions, and blocks until they've
+// all finished. compact is invoked once per group with its blocks and hash string, the same
+// hash string used today for per-hash ownership sharding.
+func compactBatch(selector CompactionBlockSelector, compact func(blocks []*backend.BlockMeta, hashString string)) {
+	groups, hashes := selector.BlocksToCompactBatch()
+	if len(groups) == 0 {
+		return
+	}
+
+	sem := make(chan struct{}, maxParallelCompactions)
+	var wg sync.WaitGroup
+
+	for i := range groups {
+		blocks, hashString := groups[i], hashes[i]
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			compact(blocks, hashString)
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,117 @@
+package tempodb
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+var metricNoCompactMarked = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempodb",
+	Subsystem: "compactor",
+	Name:      "blocks_marked_for_no_compact_total",
+	Help:      "Total number of blocks marked to be excluded from compaction.",
+}, []string{"reason"})
+
+// noCompactMarkersKeyPath is the flat, per-tenant directory every no-compact marker lives
+// under, keyed by BlockID rather than nested inside each block's own key path. Keeping markers
+// in one listable prefix, independent of the block they belong to, is what lets noCompactBlocks
+// find every marked block with a single List call instead of one Read per block.
+func noCompactMarkersKeyPath(tenantID string) backend.KeyPath {
+	return backend.KeyPath{tenantID, "markers"}
+}
+
+// MarkBlockNoCompact writes a no-compact marker for the given block, excluding it from future
+// compaction until the marker is cleared with ClearBlockNoCompact.
+func MarkBlockNoCompact(ctx context.Context, w backend.RawWriter, tenantID string, blockID backend.BlockID, reason string) error {
+	mark := &backend.NoCompactMark{
+		BlockID: blockID,
+		Reason:  reason,
+	}
+
+	data, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+
+	err = w.Write(ctx, backend.NoCompactMarkFilename, append(noCompactMarkersKeyPath(tenantID), blockID.String()), data, false)
+	if err != nil {
+		return err
+	}
+
+	metricNoCompactMarked.WithLabelValues(reason).Inc()
+
+	return nil
+}
+
+// ClearBlockNoCompact removes a block's no-compact marker, making it eligible for compaction
+// again.
+func ClearBlockNoCompact(ctx context.Context, w backend.RawWriter, tenantID string, blockID backend.BlockID) error {
+	return w.Delete(ctx, backend.NoCompactMarkFilename, append(noCompactMarkersKeyPath(tenantID), blockID.String()))
+}
+
+// loadNoCompactMark reads a block's no-compact marker, if any. A not-found error from the
+// reader is expected for the common case of an unmarked block and is surfaced to the caller
+// to interpret, since what counts as "not found" is backend-specific.
+func loadNoCompactMark(ctx context.Context, r backend.RawReader, tenantID string, blockID backend.BlockID) (*backend.NoCompactMark, error) {
+	data, err := r.Read(ctx, backend.NoCompactMarkFilename, append(noCompactMarkersKeyPath(tenantID), blockID.String()), false)
+	if err != nil {
+		return nil, err
+	}
+
+	mark := &backend.NoCompactMark{}
+	if err := json.Unmarshal(data, mark); err != nil {
+		return nil, err
+	}
+
+	return mark, nil
+}
+
+// noCompactBlocks returns every block in blocklist that currently carries a no-compact marker,
+// keyed by BlockID. This is the hook passed as the noCompactBlocks parameter to
+// newSimpleBlockSelector, newTimeWindowBlockSelector and newLeveledBlockSelector, so the poll
+// cycle that builds a tenant's blocklist can tell the selectors which blocks to quarantine.
+//
+// The overwhelmingly common case is that few, if any, blocks in a tenant are marked, so this
+// lists the tenant's markers directory once rather than probing every block in blocklist with
+// its own Read - the cost no longer scales with the size of the tenant's blocklist, only with
+// how many blocks are actually marked.
+func noCompactBlocks(ctx context.Context, r backend.RawReader, tenantID string, blocklist []*backend.BlockMeta) map[backend.BlockID]*backend.NoCompactMark {
+	marks := make(map[backend.BlockID]*backend.NoCompactMark)
+
+	markedIDs, err := r.List(ctx, noCompactMarkersKeyPath(tenantID))
+	if err != nil {
+		// couldn't list markers at all - treat every block as compactable rather than
+		// quarantine the whole tenant on a transient listing error
+		return marks
+	}
+	if len(markedIDs) == 0 {
+		return marks
+	}
+
+	inBlocklist := make(map[string]backend.BlockID, len(blocklist))
+	for _, b := range blocklist {
+		inBlocklist[b.BlockID.String()] = b.BlockID
+	}
+
+	for _, idStr := range markedIDs {
+		blockID, ok := inBlocklist[idStr]
+		if !ok {
+			// marked block isn't (or isn't yet) in this blocklist, nothing to quarantine
+			continue
+		}
+
+		mark, err := loadNoCompactMark(ctx, r, tenantID, blockID)
+		if err != nil {
+			// listed but failed to load, e.g. a transient read error - treat as compactable
+			continue
+		}
+		marks[blockID] = mark
+	}
+
+	return marks
+}
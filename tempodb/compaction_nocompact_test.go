@@ -0,0 +1,139 @@
+package tempodb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// fakeRawBackend is a minimal in-memory backend.RawReader/RawWriter used to exercise marker
+// persistence without a real backend.
+type fakeRawBackend struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newFakeRawBackend() *fakeRawBackend {
+	return &fakeRawBackend{data: make(map[string][]byte)}
+}
+
+func (f *fakeRawBackend) rawKey(name string, keypath backend.KeyPath) string {
+	return strings.Join(append(append([]string{}, keypath...), name), "/")
+}
+
+func (f *fakeRawBackend) Read(_ context.Context, name string, keypath backend.KeyPath, _ bool) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, ok := f.data[f.rawKey(name, keypath)]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return data, nil
+}
+
+func (f *fakeRawBackend) Write(_ context.Context, name string, keypath backend.KeyPath, data []byte, _ bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.data[f.rawKey(name, keypath)] = data
+	return nil
+}
+
+func (f *fakeRawBackend) Delete(_ context.Context, name string, keypath backend.KeyPath) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.data, f.rawKey(name, keypath))
+	return nil
+}
+
+// List returns the first path segment after keypath of every stored key under keypath, i.e. a
+// single-level listing of keypath's immediate children.
+func (f *fakeRawBackend) List(_ context.Context, keypath backend.KeyPath) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	prefix := strings.Join(keypath, "/") + "/"
+
+	var names []string
+	for key := range f.data {
+		rest := strings.TrimPrefix(key, prefix)
+		if rest == key {
+			continue
+		}
+		names = append(names, strings.SplitN(rest, "/", 2)[0])
+	}
+
+	return names, nil
+}
+
+func TestNoCompactMarkPersistsAcrossRestarts(t *testing.T) {
+	ctx := context.Background()
+	be := newFakeRawBackend()
+	tenant := "test-tenant"
+	blockID := newTestBlockMeta(tenant, time.Now(), time.Now(), 0, 1).BlockID
+
+	if err := MarkBlockNoCompact(ctx, be, tenant, blockID, backend.NoCompactReasonTooBig); err != nil {
+		t.Fatalf("MarkBlockNoCompact: %v", err)
+	}
+
+	// a "restart" doesn't cache anything in-process - a fresh read against the same backend
+	// must still see the marker
+	mark, err := loadNoCompactMark(ctx, be, tenant, blockID)
+	if err != nil {
+		t.Fatalf("loadNoCompactMark: %v", err)
+	}
+	if mark.Reason != backend.NoCompactReasonTooBig {
+		t.Fatalf("expected reason %q, got %q", backend.NoCompactReasonTooBig, mark.Reason)
+	}
+
+	if err := ClearBlockNoCompact(ctx, be, tenant, blockID); err != nil {
+		t.Fatalf("ClearBlockNoCompact: %v", err)
+	}
+	if _, err := loadNoCompactMark(ctx, be, tenant, blockID); err == nil {
+		t.Fatal("expected marker to be gone after ClearBlockNoCompact")
+	}
+}
+
+func TestNoCompactBlocksHookSkipsMarkedBlocksWithoutBreakingTheGroup(t *testing.T) {
+	ctx := context.Background()
+	be := newFakeRawBackend()
+	tenant := "test-tenant"
+	windowStart := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// one more block than inputBlocks requires, so the group still forms once the marked
+	// block in the middle is excluded
+	var blocklist []*backend.BlockMeta
+	for i := 0; i < inputBlocks+1; i++ {
+		start := windowStart.Add(time.Duration(i) * time.Minute)
+		blocklist = append(blocklist, newTestBlockMeta(tenant, start, start.Add(time.Minute), 0, 100))
+	}
+
+	marked := blocklist[len(blocklist)/2]
+	if err := MarkBlockNoCompact(ctx, be, tenant, marked.BlockID, backend.NoCompactReasonManual); err != nil {
+		t.Fatalf("MarkBlockNoCompact: %v", err)
+	}
+
+	marks := noCompactBlocks(ctx, be, tenant, blocklist)
+	if len(marks) != 1 {
+		t.Fatalf("expected exactly 1 marked block, got %d", len(marks))
+	}
+
+	twbs := newTimeWindowBlockSelector(blocklist, time.Hour, marks, &CompactorConfig{RetainFreshBlockWindow: false})
+
+	got, _ := twbs.BlocksToCompact()
+	if got == nil {
+		t.Fatal("expected the surrounding blocks to still form a group")
+	}
+	for _, b := range got {
+		if b.BlockID == marked.BlockID {
+			t.Fatal("marked block should have been excluded from the group")
+		}
+	}
+}
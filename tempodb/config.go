@@ -0,0 +1,35 @@
+package tempodb
+
+// CompactorConfig is the set of compaction options that apply to a tenant's compaction cycle
+// regardless of which CompactionBlockSelector its blocks get routed through.
+type CompactorConfig struct {
+	// RetainFreshBlockWindow, if true, excludes the single freshest shortest-range block
+	// from every compaction cycle, so operators have a window to piece-wise copy a
+	// just-flushed block out of the backend before it's rewritten. Defaults to true - see
+	// DefaultCompactorConfig.
+	RetainFreshBlockWindow bool
+
+	// Leveled configures leveled compaction for this tenant. A nil value means leveled
+	// compaction isn't in use, which also means RetainFreshBlockWindow falls back to
+	// excluding the single block with the newest EndTime rather than reasoning about levels.
+	Leveled *LeveledCompactionConfig
+}
+
+// DefaultCompactorConfig returns a CompactorConfig with tempodb's defaults.
+func DefaultCompactorConfig() *CompactorConfig {
+	return &CompactorConfig{
+		RetainFreshBlockWindow: true,
+	}
+}
+
+// retainFreshBlockWindow reports whether the fresh-block exclusion should run, defaulting to
+// on when no config is supplied.
+func (c *CompactorConfig) retainFreshBlockWindow() bool {
+	return c == nil || c.RetainFreshBlockWindow
+}
+
+// levelAware reports whether leveled compaction is configured, which is the only thing that
+// determines whether fresh-block exclusion can reason about compaction levels.
+func (c *CompactorConfig) levelAware() bool {
+	return c != nil && c.Leveled != nil
+}